@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/Gardego5/go-serverless-yt/pkg/handlers"
+	"github.com/Gardego5/go-serverless-yt/pkg/user"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+var (
+	dynaClient user.DynamoDBAPI
+	tableName  = os.Getenv("TABLE_NAME")
+)
+
+func main() {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		panic(err)
+	}
+
+	dynaClient = dynamodb.NewFromConfig(cfg)
+
+	lambda.Start(handler)
+}
+
+func handler(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if strings.HasSuffix(req.Path, ":batch") {
+		switch req.HTTPMethod {
+		case "POST":
+			return handlers.BulkCreateUsers(ctx, req, tableName, dynaClient)
+		case "DELETE":
+			return handlers.BulkDeleteUsers(ctx, req, tableName, dynaClient)
+		default:
+			return handlers.UnhandledMethod()
+		}
+	}
+
+	switch req.HTTPMethod {
+	case "GET":
+		return handlers.GetUser(ctx, req, tableName, dynaClient)
+	case "POST":
+		return handlers.CreateUser(ctx, req, tableName, dynaClient)
+	case "PUT":
+		return handlers.UpdateUser(ctx, req, tableName, dynaClient)
+	case "PATCH":
+		return handlers.PatchUser(ctx, req, tableName, dynaClient)
+	case "DELETE":
+		return handlers.DeleteUser(ctx, req, tableName, dynaClient)
+	default:
+		return handlers.UnhandledMethod()
+	}
+}
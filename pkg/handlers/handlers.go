@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"strconv"
+
 	"github.com/Gardego5/go-serverless-yt/pkg/user"
 
 	"net/http"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go-v2/aws"
 )
 
 var ErrorMethodNotAllowed = "Method not allowed"
@@ -16,18 +19,45 @@ type ErrorBody struct {
 	ErrorMsg *string `json:"error,omitempty"`
 }
 
-func GetUser(req events.APIGatewayProxyRequest, tableName string, dynaClient dynamodbiface.DynamoDBAPI) (*events.APIGatewayProxyResponse, error) {
+// ConflictBody is the response body for a 409/404 raised by a failed
+// conditional write. Existing carries whatever ReturnValuesOnConditionCheckFailure
+// returned, so the caller doesn't lose all context about the conflict.
+type ConflictBody struct {
+	ErrorMsg *string    `json:"error,omitempty"`
+	Existing *user.User `json:"existing,omitempty"`
+}
+
+// conflictResponse maps a write error to its HTTP response, surfacing the
+// conflicting record when err is a *user.ConflictError.
+func conflictResponse(err error, statusCode int) (*events.APIGatewayProxyResponse, error) {
+	var conflictErr *user.ConflictError
+	if errors.As(err, &conflictErr) {
+		return apiResponse(statusCode, ConflictBody{aws.String(conflictErr.Error()), conflictErr.Existing})
+	}
+
+	return apiResponse(http.StatusInternalServerError, ErrorBody{aws.String(err.Error())})
+}
+
+func GetUser(ctx context.Context, req events.APIGatewayProxyRequest, tableName string, dynaClient user.DynamoDBAPI) (*events.APIGatewayProxyResponse, error) {
 	email := req.PathParameters["email"]
 
 	if len(email) > 0 {
-		result, err := user.FetchUser(email, tableName, dynaClient)
+		result, err := user.FetchUser(ctx, email, tableName, dynaClient)
 		if err != nil {
 			return apiResponse(http.StatusInternalServerError, ErrorBody{aws.String(err.Error())})
 		}
 
 		return apiResponse(http.StatusOK, result)
 	} else {
-		result, err := user.FetchUsers(tableName, dynaClient)
+		opts := user.QueryOptions{
+			LastName: req.QueryStringParameters["lastName"],
+			Cursor:   req.QueryStringParameters["cursor"],
+		}
+		if limit, err := strconv.Atoi(req.QueryStringParameters["limit"]); err == nil {
+			opts.Limit = int32(limit)
+		}
+
+		result, err := user.QueryUsers(ctx, tableName, dynaClient, opts)
 		if err != nil {
 			return apiResponse(http.StatusInternalServerError, ErrorBody{aws.String(err.Error())})
 		}
@@ -36,30 +66,57 @@ func GetUser(req events.APIGatewayProxyRequest, tableName string, dynaClient dyn
 	}
 }
 
-func CreateUser(req events.APIGatewayProxyRequest, tableName string, dynaClient dynamodbiface.DynamoDBAPI) (*events.APIGatewayProxyResponse, error) {
-	result, err := user.CreateUser(req, tableName, dynaClient)
+func CreateUser(ctx context.Context, req events.APIGatewayProxyRequest, tableName string, dynaClient user.DynamoDBAPI) (*events.APIGatewayProxyResponse, error) {
+	result, err := user.CreateUser(ctx, req, tableName, dynaClient)
 	if err != nil {
-		return apiResponse(http.StatusInternalServerError, ErrorBody{aws.String(err.Error())})
+		return conflictResponse(err, http.StatusConflict)
 	}
 
 	return apiResponse(http.StatusCreated, result)
 }
 
-func UpdateUser(req events.APIGatewayProxyRequest, tableName string, dynaClient dynamodbiface.DynamoDBAPI) (*events.APIGatewayProxyResponse, error) {
-	result, err := user.UpdateUser(req, tableName, dynaClient)
+func UpdateUser(ctx context.Context, req events.APIGatewayProxyRequest, tableName string, dynaClient user.DynamoDBAPI) (*events.APIGatewayProxyResponse, error) {
+	result, err := user.UpdateUser(ctx, req, tableName, dynaClient)
 	if err != nil {
-		return apiResponse(http.StatusInternalServerError, ErrorBody{aws.String(err.Error())})
+		return conflictResponse(err, http.StatusNotFound)
 	}
 
 	return apiResponse(http.StatusCreated, result)
 }
 
-func DeleteUser(req events.APIGatewayProxyRequest, tableName string, dynaClient dynamodbiface.DynamoDBAPI) (*events.APIGatewayProxyResponse, error) {
-	err := user.DeleteUser(req, tableName, dynaClient)
+func PatchUser(ctx context.Context, req events.APIGatewayProxyRequest, tableName string, dynaClient user.DynamoDBAPI) (*events.APIGatewayProxyResponse, error) {
+	result, err := user.PatchUser(ctx, req, tableName, dynaClient)
+	if err != nil {
+		return conflictResponse(err, http.StatusNotFound)
+	}
+
+	return apiResponse(http.StatusOK, result)
+}
+
+func BulkCreateUsers(ctx context.Context, req events.APIGatewayProxyRequest, tableName string, dynaClient user.DynamoDBAPI) (*events.APIGatewayProxyResponse, error) {
+	results, err := user.BulkCreateUsers(ctx, req, tableName, dynaClient)
+	if err != nil {
+		return apiResponse(http.StatusInternalServerError, ErrorBody{aws.String(err.Error())})
+	}
+
+	return apiResponse(http.StatusMultiStatus, results)
+}
+
+func BulkDeleteUsers(ctx context.Context, req events.APIGatewayProxyRequest, tableName string, dynaClient user.DynamoDBAPI) (*events.APIGatewayProxyResponse, error) {
+	results, err := user.BulkDeleteUsers(ctx, req, tableName, dynaClient)
 	if err != nil {
 		return apiResponse(http.StatusInternalServerError, ErrorBody{aws.String(err.Error())})
 	}
 
+	return apiResponse(http.StatusMultiStatus, results)
+}
+
+func DeleteUser(ctx context.Context, req events.APIGatewayProxyRequest, tableName string, dynaClient user.DynamoDBAPI) (*events.APIGatewayProxyResponse, error) {
+	err := user.DeleteUser(ctx, req, tableName, dynaClient)
+	if err != nil {
+		return conflictResponse(err, http.StatusNotFound)
+	}
+
 	return apiResponse(http.StatusNoContent, nil)
 }
 
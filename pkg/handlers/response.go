@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// apiResponse JSON-encodes body into an API Gateway proxy response with the
+// given status code.
+func apiResponse(statusCode int, body interface{}) (*events.APIGatewayProxyResponse, error) {
+	resp := events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+	}
+
+	stringBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = string(stringBody)
+
+	return &resp, nil
+}
@@ -0,0 +1,360 @@
+// Package txn provides a fluent builder over DynamoDB's TransactWriteItems,
+// so a single request can make several related writes (e.g. creating a user
+// and reserving its email in a uniqueness table) atomically.
+package txn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoDBAPI is the subset of the aws-sdk-go-v2 DynamoDB client that txn
+// depends on.
+type DynamoDBAPI interface {
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+// Op builds a single TransactWriteItem entry. Put, Update, Delete, and
+// ConditionCheck all return an Op.
+type Op interface {
+	build() (types.TransactWriteItem, error)
+}
+
+// Builder accumulates Ops and runs them as a single atomic TransactWriteItems
+// call.
+type Builder struct {
+	ops []Op
+}
+
+// New returns an empty transaction builder.
+func New() *Builder {
+	return &Builder{}
+}
+
+// Add appends one or more operations to the transaction.
+func (b *Builder) Add(ops ...Op) *Builder {
+	b.ops = append(b.ops, ops...)
+	return b
+}
+
+// Run submits the accumulated operations as a single TransactWriteItems call.
+// If the transaction is canceled, the returned error is a *CanceledError
+// mapping each canceled reason back to the operation that caused it.
+func (b *Builder) Run(ctx context.Context, dynaClient DynamoDBAPI) error {
+	items := make([]types.TransactWriteItem, 0, len(b.ops))
+	for _, op := range b.ops {
+		item, err := op.build()
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+	}
+
+	_, err := dynaClient.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if err != nil {
+		var canceled *types.TransactionCanceledException
+		if errors.As(err, &canceled) {
+			return newCanceledError(canceled)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// CancellationReason is why a single operation within a transaction was
+// canceled.
+type CancellationReason struct {
+	Index   int
+	Code    string
+	Message string
+	Item    map[string]types.AttributeValue
+}
+
+// CanceledError reports the per-operation reasons a transaction was canceled.
+// Operations that weren't the cause of the cancellation (Code "None") are
+// omitted.
+type CanceledError struct {
+	Reasons []CancellationReason
+}
+
+func (e *CanceledError) Error() string {
+	return fmt.Sprintf("transaction canceled: %d operation(s) caused the cancellation", len(e.Reasons))
+}
+
+func newCanceledError(canceled *types.TransactionCanceledException) *CanceledError {
+	reasons := make([]CancellationReason, 0, len(canceled.CancellationReasons))
+	for i, r := range canceled.CancellationReasons {
+		if aws.ToString(r.Code) == "None" {
+			continue
+		}
+
+		reasons = append(reasons, CancellationReason{
+			Index:   i,
+			Code:    aws.ToString(r.Code),
+			Message: aws.ToString(r.Message),
+			Item:    r.Item,
+		})
+	}
+
+	return &CanceledError{Reasons: reasons}
+}
+
+// condition is embedded by every Op to provide the shared
+// If/IfNotExists helpers.
+type condition struct {
+	expr   *string
+	names  map[string]string
+	values map[string]types.AttributeValue
+	err    error
+}
+
+func (c *condition) ifNotExists(key string) {
+	c.expr = aws.String(fmt.Sprintf("attribute_not_exists(%s)", key))
+}
+
+func (c *condition) if_(expr string, names map[string]string, values map[string]interface{}) {
+	c.expr = aws.String(expr)
+	c.names = names
+
+	av, err := attributevalue.MarshalMap(values)
+	if err != nil {
+		c.err = err
+		return
+	}
+	c.values = av
+}
+
+// PutOp is the Op built by Put.
+type PutOp struct {
+	condition
+	table string
+	item  map[string]types.AttributeValue
+	err   error
+}
+
+// Put builds an Op that puts v into tableName.
+func Put(tableName string, v interface{}) *PutOp {
+	item, err := attributevalue.MarshalMap(v)
+	return &PutOp{table: tableName, item: item, err: err}
+}
+
+// IfNotExists conditions the put on key not already being present in the
+// table, so a race with a concurrent create fails the whole transaction.
+func (p *PutOp) IfNotExists(key string) *PutOp {
+	p.ifNotExists(key)
+	return p
+}
+
+// If conditions the put on an arbitrary condition expression.
+func (p *PutOp) If(expr string, names map[string]string, values map[string]interface{}) *PutOp {
+	p.if_(expr, names, values)
+	return p
+}
+
+func (p *PutOp) build() (types.TransactWriteItem, error) {
+	if p.err != nil {
+		return types.TransactWriteItem{}, p.err
+	}
+	if p.condition.err != nil {
+		return types.TransactWriteItem{}, p.condition.err
+	}
+
+	return types.TransactWriteItem{Put: &types.Put{
+		TableName:                 aws.String(p.table),
+		Item:                      p.item,
+		ConditionExpression:       p.condition.expr,
+		ExpressionAttributeNames:  p.condition.names,
+		ExpressionAttributeValues: p.condition.values,
+	}}, nil
+}
+
+// UpdateOp is the Op built by Update.
+type UpdateOp struct {
+	condition
+	table            string
+	key              map[string]types.AttributeValue
+	updateExpr       string
+	updateNames      map[string]string
+	updateValues     map[string]types.AttributeValue
+	keyErr           error
+}
+
+// Update builds an Op that applies updateExpr (e.g. "SET #n = :v") against
+// the item identified by key in tableName.
+func Update(tableName string, key interface{}, updateExpr string, names map[string]string, values map[string]interface{}) *UpdateOp {
+	k, keyErr := attributevalue.MarshalMap(key)
+
+	av, err := attributevalue.MarshalMap(values)
+	if keyErr == nil {
+		keyErr = err
+	}
+
+	return &UpdateOp{
+		table:        tableName,
+		key:          k,
+		updateExpr:   updateExpr,
+		updateNames:  names,
+		updateValues: av,
+		keyErr:       keyErr,
+	}
+}
+
+// IfNotExists conditions the update on key not already being present in the
+// table.
+func (u *UpdateOp) IfNotExists(key string) *UpdateOp {
+	u.ifNotExists(key)
+	return u
+}
+
+// If conditions the update on an arbitrary condition expression.
+func (u *UpdateOp) If(expr string, names map[string]string, values map[string]interface{}) *UpdateOp {
+	u.if_(expr, names, values)
+	return u
+}
+
+func (u *UpdateOp) build() (types.TransactWriteItem, error) {
+	if u.keyErr != nil {
+		return types.TransactWriteItem{}, u.keyErr
+	}
+	if u.condition.err != nil {
+		return types.TransactWriteItem{}, u.condition.err
+	}
+
+	names := mergeStringMaps(u.updateNames, u.condition.names)
+	values := mergeAttributeValueMaps(u.updateValues, u.condition.values)
+
+	return types.TransactWriteItem{Update: &types.Update{
+		TableName:                 aws.String(u.table),
+		Key:                       u.key,
+		UpdateExpression:          aws.String(u.updateExpr),
+		ConditionExpression:       u.condition.expr,
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}}, nil
+}
+
+// DeleteOp is the Op built by Delete.
+type DeleteOp struct {
+	condition
+	table  string
+	key    map[string]types.AttributeValue
+	keyErr error
+}
+
+// Delete builds an Op that deletes the item identified by key from
+// tableName.
+func Delete(tableName string, key interface{}) *DeleteOp {
+	k, err := attributevalue.MarshalMap(key)
+	return &DeleteOp{table: tableName, key: k, keyErr: err}
+}
+
+// IfNotExists conditions the delete on key not already being present in the
+// table. This is rarely useful on its own but is provided for symmetry with
+// the other Ops.
+func (d *DeleteOp) IfNotExists(key string) *DeleteOp {
+	d.ifNotExists(key)
+	return d
+}
+
+// If conditions the delete on an arbitrary condition expression.
+func (d *DeleteOp) If(expr string, names map[string]string, values map[string]interface{}) *DeleteOp {
+	d.if_(expr, names, values)
+	return d
+}
+
+func (d *DeleteOp) build() (types.TransactWriteItem, error) {
+	if d.keyErr != nil {
+		return types.TransactWriteItem{}, d.keyErr
+	}
+	if d.condition.err != nil {
+		return types.TransactWriteItem{}, d.condition.err
+	}
+
+	return types.TransactWriteItem{Delete: &types.Delete{
+		TableName:                 aws.String(d.table),
+		Key:                       d.key,
+		ConditionExpression:       d.condition.expr,
+		ExpressionAttributeNames:  d.condition.names,
+		ExpressionAttributeValues: d.condition.values,
+	}}, nil
+}
+
+// ConditionCheckOp is the Op built by ConditionCheck. It doesn't write
+// anything itself; it only fails the transaction if its condition doesn't
+// hold, e.g. to check that a uniqueness row is still free without touching it.
+type ConditionCheckOp struct {
+	condition
+	table  string
+	key    map[string]types.AttributeValue
+	keyErr error
+}
+
+// ConditionCheck builds an Op that fails the transaction unless expr holds
+// for the item identified by key in tableName.
+func ConditionCheck(tableName string, key interface{}, expr string, names map[string]string, values map[string]interface{}) *ConditionCheckOp {
+	k, err := attributevalue.MarshalMap(key)
+
+	c := &ConditionCheckOp{table: tableName, key: k, keyErr: err}
+	c.if_(expr, names, values)
+	return c
+}
+
+// IfNotExists builds a ConditionCheck that passes only when key is absent
+// from tableName, e.g. to check that a uniqueness row is still free.
+func IfNotExists(tableName string, key interface{}, keyAttr string) *ConditionCheckOp {
+	k, err := attributevalue.MarshalMap(key)
+
+	c := &ConditionCheckOp{table: tableName, key: k, keyErr: err}
+	c.ifNotExists(keyAttr)
+	return c
+}
+
+func (c *ConditionCheckOp) build() (types.TransactWriteItem, error) {
+	if c.keyErr != nil {
+		return types.TransactWriteItem{}, c.keyErr
+	}
+	if c.condition.err != nil {
+		return types.TransactWriteItem{}, c.condition.err
+	}
+
+	return types.TransactWriteItem{ConditionCheck: &types.ConditionCheck{
+		TableName:                 aws.String(c.table),
+		Key:                       c.key,
+		ConditionExpression:       c.condition.expr,
+		ExpressionAttributeNames:  c.condition.names,
+		ExpressionAttributeValues: c.condition.values,
+	}}, nil
+}
+
+func mergeStringMaps(ms ...map[string]string) map[string]string {
+	out := map[string]string{}
+	for _, m := range ms {
+		for k, v := range m {
+			out[k] = v
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func mergeAttributeValueMaps(ms ...map[string]types.AttributeValue) map[string]types.AttributeValue {
+	out := map[string]types.AttributeValue{}
+	for _, m := range ms {
+		for k, v := range m {
+			out[k] = v
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
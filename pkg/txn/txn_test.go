@@ -0,0 +1,74 @@
+package txn
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type mockDynamoDBAPI struct {
+	transactWriteItems func(context.Context, *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+func (m *mockDynamoDBAPI) TransactWriteItems(ctx context.Context, in *dynamodb.TransactWriteItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	return m.transactWriteItems(ctx, in)
+}
+
+func TestRun_SubmitsAllOps(t *testing.T) {
+	var captured *dynamodb.TransactWriteItemsInput
+
+	client := &mockDynamoDBAPI{
+		transactWriteItems: func(_ context.Context, in *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+			captured = in
+			return &dynamodb.TransactWriteItemsOutput{}, nil
+		},
+	}
+
+	type user struct {
+		Email string `json:"email"`
+	}
+
+	err := New().Add(
+		Put("users", user{Email: "jane@example.com"}).IfNotExists("email"),
+		ConditionCheck("emails", map[string]string{"email": "jane@example.com"}, "attribute_not_exists(email)", nil, nil),
+	).Run(context.Background(), client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(captured.TransactItems) != 2 {
+		t.Fatalf("expected 2 transact items, got %d", len(captured.TransactItems))
+	}
+	if captured.TransactItems[0].Put == nil || *captured.TransactItems[0].Put.ConditionExpression != "attribute_not_exists(email)" {
+		t.Fatalf("expected the put's IfNotExists condition to be set")
+	}
+}
+
+func TestRun_MapsCanceledReasons(t *testing.T) {
+	client := &mockDynamoDBAPI{
+		transactWriteItems: func(context.Context, *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+			return nil, &types.TransactionCanceledException{
+				CancellationReasons: []types.CancellationReason{
+					{Code: aws.String("None")},
+					{Code: aws.String("ConditionalCheckFailed"), Message: aws.String("already exists")},
+				},
+			}
+		},
+	}
+
+	err := New().Add(
+		Put("users", struct{ Email string }{Email: "jane@example.com"}),
+		IfNotExists("emails", map[string]string{"email": "jane@example.com"}, "email"),
+	).Run(context.Background(), client)
+
+	var canceled *CanceledError
+	if !errors.As(err, &canceled) {
+		t.Fatalf("expected a *CanceledError, got %v", err)
+	}
+	if len(canceled.Reasons) != 1 || canceled.Reasons[0].Index != 1 {
+		t.Fatalf("expected only the second op's reason to survive, got %+v", canceled.Reasons)
+	}
+}
@@ -1,17 +1,22 @@
 package user
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/mail"
 	"strings"
+	"time"
+
+	"github.com/Gardego5/go-serverless-yt/pkg/txn"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
 var (
@@ -19,6 +24,7 @@ var (
 	ErrorFailedToFetchRecord     = "failed to fetch record"
 	ErrorInvalidUserData         = "invalid user data"
 	ErrorInvalidEmail            = "invalid email"
+	ErrorInvalidCursor           = "invalid cursor"
 	ErrorCouldNotMarshalItem     = "could not marshal item"
 	ErrorCouldNotDeleteItem      = "could not delete item"
 	ErrorCouldNotPutItem         = "could not put item"
@@ -26,68 +32,227 @@ var (
 	ErrorUserDoesNotExist        = "user does not exist"
 )
 
+// LastNameIndex is the GSI that QueryUsers queries against when the caller
+// filters by last name, instead of falling back to a full-table Scan.
+const LastNameIndex = "lastName-index"
+
+// DefaultPageSize is the page size QueryUsers uses when the caller doesn't
+// specify a limit.
+const DefaultPageSize = 25
+
+// batchWriteChunkSize is the largest number of items BatchWriteItem accepts
+// per call.
+const batchWriteChunkSize = 25
+
+// maxBatchWriteRetries bounds the BatchWriteItem attempts per chunk (the first
+// call plus its exponential-backoff retries of UnprocessedItems) that
+// BulkCreateUsers and BulkDeleteUsers make before giving up on them.
+const maxBatchWriteRetries = 5
+
+// BulkResult reports the outcome of a single item within a bulk create or
+// delete, so a partially-failed batch is debuggable instead of failing
+// all-or-nothing.
+type BulkResult struct {
+	Email   string `json:"email"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
 type User struct {
-	Email     string `json:"email"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
+	Email     string `json:"email" dynamodbav:"email"`
+	FirstName string `json:"firstName" dynamodbav:"firstName"`
+	LastName  string `json:"lastName" dynamodbav:"lastName"`
+}
+
+// UsersPage is a single page of a QueryUsers listing. NextCursor is empty
+// once the listing is exhausted.
+type UsersPage struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// ConflictError wraps a ConditionalCheckFailedException with the item
+// DynamoDB returned via ReturnValuesOnConditionCheckFailure, so callers don't
+// lose all context about why a write failed. Existing is nil if DynamoDB
+// didn't return an item (e.g. the condition failed because the item didn't
+// exist at all).
+type ConflictError struct {
+	Reason   string
+	Existing *User
+}
+
+func (e *ConflictError) Error() string { return e.Reason }
+
+// QueryOptions controls a QueryUsers listing. LastName, when set, routes the
+// listing through LastNameIndex via Query instead of Scan.
+type QueryOptions struct {
+	LastName string
+	Cursor   string
+	Limit    int32
 }
 
-func FetchUser(email string, tableName string, dynaClient dynamodbiface.DynamoDBAPI) (*User, error) {
+// DynamoDBAPI is the subset of the aws-sdk-go-v2 DynamoDB client that pkg/user
+// depends on. It is satisfied by both *dynamodb.Client and a DAX client, so
+// callers can swap in read-through caching without any code changes here.
+type DynamoDBAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+}
+
+func FetchUser(ctx context.Context, email string, tableName string, dynaClient DynamoDBAPI) (*User, error) {
 	input := dynamodb.GetItemInput{
 		TableName: aws.String(tableName),
-		Key: map[string]*dynamodb.AttributeValue{
-			"email": {S: aws.String(email)},
+		Key: map[string]types.AttributeValue{
+			"email": &types.AttributeValueMemberS{Value: email},
 		},
 	}
 
-	result, err := dynaClient.GetItem(&input)
+	result, err := dynaClient.GetItem(ctx, &input)
 	if err != nil {
 		return nil, errors.New(ErrorFailedToFetchRecord)
 	}
 
 	item := new(User)
-	err = dynamodbattribute.UnmarshalMap(result.Item, item)
-	if err != nil {
+	if err := attributevalue.UnmarshalMap(result.Item, item); err != nil {
 		return nil, errors.New(ErrorFailedToUnmarshalRecord)
 	}
 
 	return item, nil
 }
 
-func FetchUsers(tableName string, dynaClient dynamodbiface.DynamoDBAPI) (*[]User, error) {
-	input := dynamodb.ScanInput{
-		TableName: aws.String(tableName),
+// QueryUsers lists users a page at a time. When opts.LastName is set it
+// queries LastNameIndex instead of scanning the whole table, so listings
+// filtered by last name stay cheap as the table grows. Cursor is an opaque,
+// base64-encoded LastEvaluatedKey handed back as NextCursor on the previous
+// page.
+func QueryUsers(ctx context.Context, tableName string, dynaClient DynamoDBAPI, opts QueryOptions) (*UsersPage, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
 	}
 
-	users := []User{}
+	startKey, err := decodeCursor(opts.Cursor)
+	if err != nil {
+		return nil, errors.New(ErrorInvalidCursor)
+	}
 
-	for {
-		result, err := dynaClient.Scan(&input)
+	var items []map[string]types.AttributeValue
+	var lastEvaluatedKey map[string]types.AttributeValue
+
+	if opts.LastName != "" {
+		input := dynamodb.QueryInput{
+			TableName:              aws.String(tableName),
+			IndexName:              aws.String(LastNameIndex),
+			KeyConditionExpression: aws.String("#lastName = :lastName"),
+			ExpressionAttributeNames: map[string]string{
+				"#lastName": "lastName",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":lastName": &types.AttributeValueMemberS{Value: opts.LastName},
+			},
+			ExclusiveStartKey: startKey,
+			Limit:             aws.Int32(limit),
+		}
 
+		result, err := dynaClient.Query(ctx, &input)
 		if err != nil {
 			return nil, errors.New(ErrorFailedToFetchRecord)
 		}
 
-		items := new([]User)
-		err = dynamodbattribute.UnmarshalListOfMaps(result.Items, items)
+		items, lastEvaluatedKey = result.Items, result.LastEvaluatedKey
+	} else {
+		input := dynamodb.ScanInput{
+			TableName:         aws.String(tableName),
+			ExclusiveStartKey: startKey,
+			Limit:             aws.Int32(limit),
+		}
 
+		result, err := dynaClient.Scan(ctx, &input)
 		if err != nil {
-			return nil, errors.New(ErrorFailedToUnmarshalRecord)
+			return nil, errors.New(ErrorFailedToFetchRecord)
 		}
 
-		users = append(users, *items...)
+		items, lastEvaluatedKey = result.Items, result.LastEvaluatedKey
+	}
 
-		if result.LastEvaluatedKey != nil {
-			input.ExclusiveStartKey = result.LastEvaluatedKey
-		} else {
-			break
-		}
+	users := []User{}
+	if err := attributevalue.UnmarshalListOfMaps(items, &users); err != nil {
+		return nil, errors.New(ErrorFailedToUnmarshalRecord)
+	}
+
+	nextCursor, err := encodeCursor(lastEvaluatedKey)
+	if err != nil {
+		return nil, errors.New(ErrorInvalidCursor)
+	}
+
+	return &UsersPage{Users: users, NextCursor: nextCursor}, nil
+}
+
+// encodeCursor and decodeCursor round-trip a LastEvaluatedKey through an
+// opaque, base64-encoded JSON blob so API consumers never see raw DynamoDB
+// key attributes.
+func encodeCursor(key map[string]types.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	plain := map[string]interface{}{}
+	if err := attributevalue.UnmarshalMap(key, &plain); err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(plain)
+	if err != nil {
+		return "", err
 	}
 
-	return &users, nil
+	return base64.URLEncoding.EncodeToString(data), nil
 }
 
-func CreateUser(req events.APIGatewayProxyRequest, tableName string, dynaClient dynamodbiface.DynamoDBAPI) (*User, error) {
+func decodeCursor(cursor string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	plain := map[string]interface{}{}
+	if err := json.Unmarshal(data, &plain); err != nil {
+		return nil, err
+	}
+
+	return attributevalue.MarshalMap(plain)
+}
+
+// conflictExisting unmarshals the item a ConditionalCheckFailedException
+// returned via ReturnValuesOnConditionCheckFailure. It returns nil, nil when
+// DynamoDB didn't return an item.
+func conflictExisting(item map[string]types.AttributeValue) (*User, error) {
+	if len(item) == 0 {
+		return nil, nil
+	}
+
+	existing := new(User)
+	if err := attributevalue.UnmarshalMap(item, existing); err != nil {
+		return nil, err
+	}
+
+	return existing, nil
+}
+
+// CreateUser creates a user with PutItem. Pass a *txn.Builder to instead
+// enqueue the put onto that transaction (e.g. to create the user and insert
+// an audit-log row atomically) — the caller is then responsible for calling
+// Run on the builder.
+func CreateUser(ctx context.Context, req events.APIGatewayProxyRequest, tableName string, dynaClient DynamoDBAPI, txnBuilder ...*txn.Builder) (*User, error) {
 	user := User{}
 
 	if err := json.Unmarshal([]byte(req.Body), &user); err != nil {
@@ -98,104 +263,358 @@ func CreateUser(req events.APIGatewayProxyRequest, tableName string, dynaClient
 		return nil, errors.New(ErrorInvalidEmail)
 	}
 
-	item, err := dynamodbattribute.MarshalMap(user)
+	if len(txnBuilder) > 0 && txnBuilder[0] != nil {
+		txnBuilder[0].Add(txn.Put(tableName, user).IfNotExists("email"))
+		return &user, nil
+	}
+
+	item, err := attributevalue.MarshalMap(user)
 	if err != nil {
 		return nil, errors.New(ErrorCouldNotMarshalItem)
 	}
 
 	input := dynamodb.PutItemInput{
-		TableName:           aws.String(tableName),
-		Item:                item,
-		ConditionExpression: aws.String("attribute_not_exists(email)"),
+		TableName:                           aws.String(tableName),
+		Item:                                item,
+		ConditionExpression:                 aws.String("attribute_not_exists(email)"),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
 	}
 
-	if _, err := dynaClient.PutItem(&input); err != nil {
-		switch err.(type) {
-		case *dynamodb.ConditionalCheckFailedException:
-			return nil, errors.New(ErrorUserAlreadyExists)
-		default:
-			fmt.Print(err)
-			return nil, errors.New(ErrorCouldNotPutItem)
+	if _, err := dynaClient.PutItem(ctx, &input); err != nil {
+		var conditionalErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionalErr) {
+			existing, uerr := conflictExisting(conditionalErr.Item)
+			if uerr != nil {
+				return nil, errors.New(ErrorFailedToUnmarshalRecord)
+			}
+			return nil, &ConflictError{Reason: ErrorUserAlreadyExists, Existing: existing}
 		}
+
+		return nil, errors.New(ErrorCouldNotPutItem)
 	}
 
 	return &user, nil
 }
 
-func UpdateUser(req events.APIGatewayProxyRequest, tableName string, dynaClient dynamodbiface.DynamoDBAPI) (*User, error) {
+// UpdateUser backs the PUT route: it replaces the stored item wholesale, so
+// the request body must be a complete User. For updating individual fields
+// use PatchUser instead.
+func UpdateUser(ctx context.Context, req events.APIGatewayProxyRequest, tableName string, dynaClient DynamoDBAPI) (*User, error) {
 	user := User{}
 	if err := json.Unmarshal([]byte(req.Body), &user); err != nil {
 		return nil, errors.New(ErrorInvalidUserData)
 	}
 
-	item, err := dynamodbattribute.MarshalMap(user)
+	if _, err := mail.ParseAddress(user.Email); err != nil {
+		return nil, errors.New(ErrorInvalidEmail)
+	}
+
+	item, err := attributevalue.MarshalMap(user)
 	if err != nil {
 		return nil, errors.New(ErrorCouldNotMarshalItem)
 	}
 
-	updates := []string{}
-	if user.FirstName != "" {
-		updates = append(updates, updateExpressionPart("fieldName"))
+	input := dynamodb.PutItemInput{
+		TableName:                           aws.String(tableName),
+		Item:                                item,
+		ConditionExpression:                 aws.String("attribute_exists(email)"),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
 	}
-	if user.LastName != "" {
-		updates = append(updates, updateExpressionPart("lastName"))
+
+	if _, err := dynaClient.PutItem(ctx, &input); err != nil {
+		var conditionalErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionalErr) {
+			existing, uerr := conflictExisting(conditionalErr.Item)
+			if uerr != nil {
+				return nil, errors.New(ErrorFailedToUnmarshalRecord)
+			}
+			return nil, &ConflictError{Reason: ErrorUserDoesNotExist, Existing: existing}
+		}
+
+		return nil, errors.New(ErrorCouldNotPutItem)
+	}
+
+	return &user, nil
+}
+
+// PatchUser backs the PATCH route: it only touches the non-empty fields of the
+// request body, building the UpdateExpression's SET clause from
+// ExpressionAttributeNames/Values so field names never collide with
+// DynamoDB reserved words. A patch with no non-empty fields besides email is
+// a no-op that just returns the current item, or ErrorUserDoesNotExist if
+// the email doesn't exist.
+func PatchUser(ctx context.Context, req events.APIGatewayProxyRequest, tableName string, dynaClient DynamoDBAPI) (*User, error) {
+	patch := User{}
+	if err := json.Unmarshal([]byte(req.Body), &patch); err != nil {
+		return nil, errors.New(ErrorInvalidUserData)
+	}
+
+	if _, err := mail.ParseAddress(patch.Email); err != nil {
+		return nil, errors.New(ErrorInvalidEmail)
+	}
+
+	key := map[string]types.AttributeValue{
+		"email": &types.AttributeValueMemberS{Value: patch.Email},
+	}
+
+	names := map[string]string{}
+	values := map[string]types.AttributeValue{}
+	sets := []string{}
+
+	if patch.FirstName != "" {
+		av, err := attributevalue.Marshal(patch.FirstName)
+		if err != nil {
+			return nil, errors.New(ErrorCouldNotMarshalItem)
+		}
+		names["#firstName"] = "firstName"
+		values[":firstName"] = av
+		sets = append(sets, "#firstName = :firstName")
+	}
+	if patch.LastName != "" {
+		av, err := attributevalue.Marshal(patch.LastName)
+		if err != nil {
+			return nil, errors.New(ErrorCouldNotMarshalItem)
+		}
+		names["#lastName"] = "lastName"
+		values[":lastName"] = av
+		sets = append(sets, "#lastName = :lastName")
+	}
+
+	if len(sets) == 0 {
+		result, err := dynaClient.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName: aws.String(tableName),
+			Key:       key,
+		})
+		if err != nil {
+			return nil, errors.New(ErrorFailedToFetchRecord)
+		}
+		if result.Item == nil {
+			return nil, errors.New(ErrorUserDoesNotExist)
+		}
+
+		current := User{}
+		if err := attributevalue.UnmarshalMap(result.Item, &current); err != nil {
+			return nil, errors.New(ErrorFailedToUnmarshalRecord)
+		}
+		return &current, nil
 	}
 
 	input := dynamodb.UpdateItemInput{
-		TableName:                 aws.String(tableName),
-		Key:                       item,
-		UpdateExpression:          aws.String(fmt.Sprintf("SET %s", strings.Join(updates, ", "))),
-		ConditionExpression:       aws.String("attribute_exists(email)"),
-		ExpressionAttributeValues: item,
-		ReturnValues:              aws.String("ALL_NEW"),
+		TableName:                           aws.String(tableName),
+		Key:                                 key,
+		UpdateExpression:                    aws.String(fmt.Sprintf("SET %s", strings.Join(sets, ", "))),
+		ExpressionAttributeNames:            names,
+		ExpressionAttributeValues:           values,
+		ConditionExpression:                 aws.String("attribute_exists(email)"),
+		ReturnValues:                        types.ReturnValueAllNew,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
 	}
 
-	result, err := dynaClient.UpdateItem(&input)
+	result, err := dynaClient.UpdateItem(ctx, &input)
 	if err != nil {
-		switch err.(type) {
-		case *dynamodb.ConditionalCheckFailedException:
-			return nil, errors.New(ErrorUserDoesNotExist)
-		default:
-			return nil, errors.New(ErrorCouldNotPutItem)
+		var conditionalErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionalErr) {
+			existing, uerr := conflictExisting(conditionalErr.Item)
+			if uerr != nil {
+				return nil, errors.New(ErrorFailedToUnmarshalRecord)
+			}
+			return nil, &ConflictError{Reason: ErrorUserDoesNotExist, Existing: existing}
 		}
+
+		return nil, errors.New(ErrorCouldNotPutItem)
 	}
 
-	if err := dynamodbattribute.UnmarshalMap(result.Attributes, &user); err != nil {
+	user := User{}
+	if err := attributevalue.UnmarshalMap(result.Attributes, &user); err != nil {
 		return nil, errors.New(ErrorFailedToUnmarshalRecord)
 	}
 
 	return &user, nil
 }
 
-func DeleteUser(req events.APIGatewayProxyRequest, tableName string, dynaClient dynamodbiface.DynamoDBAPI) error {
+func DeleteUser(ctx context.Context, req events.APIGatewayProxyRequest, tableName string, dynaClient DynamoDBAPI) error {
 	user := User{}
 	if err := json.Unmarshal([]byte(req.Body), &user); err != nil {
 		return errors.New(ErrorInvalidUserData)
 	}
 
-	item, err := dynamodbattribute.MarshalMap(user)
+	input := dynamodb.DeleteItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"email": &types.AttributeValueMemberS{Value: user.Email},
+		},
+		ConditionExpression:                 aws.String("attribute_exists(email)"),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+
+	if _, err := dynaClient.DeleteItem(ctx, &input); err != nil {
+		var conditionalErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionalErr) {
+			existing, uerr := conflictExisting(conditionalErr.Item)
+			if uerr != nil {
+				return errors.New(ErrorFailedToUnmarshalRecord)
+			}
+			return &ConflictError{Reason: ErrorUserDoesNotExist, Existing: existing}
+		}
+
+		return errors.New(ErrorCouldNotDeleteItem)
+	}
+
+	return nil
+}
+
+// BulkCreateUsers accepts a JSON array of users in the request body and
+// writes them with BatchWriteItem in chunks of 25. Each item is validated
+// independently before submission, so one bad row reports its own failure
+// instead of failing the whole batch.
+func BulkCreateUsers(ctx context.Context, req events.APIGatewayProxyRequest, tableName string, dynaClient DynamoDBAPI) ([]BulkResult, error) {
+	users := []User{}
+	if err := json.Unmarshal([]byte(req.Body), &users); err != nil {
+		return nil, errors.New(ErrorInvalidUserData)
+	}
+
+	results := make([]BulkResult, len(users))
+	writeRequests := []types.WriteRequest{}
+
+	for i, u := range users {
+		results[i] = BulkResult{Email: u.Email}
+
+		if _, err := mail.ParseAddress(u.Email); err != nil {
+			results[i].Error = ErrorInvalidEmail
+			continue
+		}
+
+		item, err := attributevalue.MarshalMap(u)
+		if err != nil {
+			results[i].Error = ErrorCouldNotMarshalItem
+			continue
+		}
+
+		writeRequests = append(writeRequests, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	}
+
+	unprocessed, err := batchWriteWithRetry(ctx, tableName, dynaClient, writeRequests)
 	if err != nil {
-		return errors.New(ErrorCouldNotMarshalItem)
+		return nil, errors.New(ErrorCouldNotPutItem)
 	}
 
-	input := dynamodb.DeleteItemInput{
-		TableName:           aws.String(tableName),
-		Key:                 item,
-		ConditionExpression: aws.String("attribute_exists(email)"),
+	failedEmails := unprocessedEmails(unprocessed, func(wr types.WriteRequest) map[string]types.AttributeValue {
+		if wr.PutRequest == nil {
+			return nil
+		}
+		return wr.PutRequest.Item
+	})
+
+	markBulkResults(results, failedEmails, ErrorCouldNotPutItem)
+
+	return results, nil
+}
+
+// BulkDeleteUsers accepts a JSON array of users (only Email is required) in
+// the request body and deletes them with BatchWriteItem in chunks of 25.
+func BulkDeleteUsers(ctx context.Context, req events.APIGatewayProxyRequest, tableName string, dynaClient DynamoDBAPI) ([]BulkResult, error) {
+	users := []User{}
+	if err := json.Unmarshal([]byte(req.Body), &users); err != nil {
+		return nil, errors.New(ErrorInvalidUserData)
 	}
 
-	if _, err := dynaClient.DeleteItem(&input); err != nil {
-		switch err.(type) {
-		case *dynamodb.ConditionalCheckFailedException:
-			return errors.New(ErrorUserDoesNotExist)
-		default:
-			return errors.New(ErrorCouldNotDeleteItem)
+	results := make([]BulkResult, len(users))
+	writeRequests := []types.WriteRequest{}
+
+	for i, u := range users {
+		results[i] = BulkResult{Email: u.Email}
+
+		if _, err := mail.ParseAddress(u.Email); err != nil {
+			results[i].Error = ErrorInvalidEmail
+			continue
 		}
+
+		key := map[string]types.AttributeValue{"email": &types.AttributeValueMemberS{Value: u.Email}}
+		writeRequests = append(writeRequests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: key}})
 	}
 
-	return nil
+	unprocessed, err := batchWriteWithRetry(ctx, tableName, dynaClient, writeRequests)
+	if err != nil {
+		return nil, errors.New(ErrorCouldNotDeleteItem)
+	}
+
+	failedEmails := unprocessedEmails(unprocessed, func(wr types.WriteRequest) map[string]types.AttributeValue {
+		if wr.DeleteRequest == nil {
+			return nil
+		}
+		return wr.DeleteRequest.Key
+	})
+
+	markBulkResults(results, failedEmails, ErrorCouldNotDeleteItem)
+
+	return results, nil
 }
 
-func updateExpressionPart(fieldName string) string {
-	return fmt.Sprintf("%s = :%s", fieldName, fieldName)
+// batchWriteWithRetry submits writeRequests in chunks of batchWriteChunkSize,
+// retrying each chunk's UnprocessedItems with exponential backoff. It returns
+// whichever requests are still unprocessed after maxBatchWriteRetries attempts.
+func batchWriteWithRetry(ctx context.Context, tableName string, dynaClient DynamoDBAPI, writeRequests []types.WriteRequest) ([]types.WriteRequest, error) {
+	var stillUnprocessed []types.WriteRequest
+
+	for start := 0; start < len(writeRequests); start += batchWriteChunkSize {
+		end := start + batchWriteChunkSize
+		if end > len(writeRequests) {
+			end = len(writeRequests)
+		}
+		pending := writeRequests[start:end]
+
+		for attempt := 0; attempt < maxBatchWriteRetries && len(pending) > 0; attempt++ {
+			if attempt > 0 {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(time.Duration(1<<uint(attempt)) * 50 * time.Millisecond):
+				}
+			}
+
+			output, err := dynaClient.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]types.WriteRequest{tableName: pending},
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			pending = output.UnprocessedItems[tableName]
+		}
+
+		stillUnprocessed = append(stillUnprocessed, pending...)
+	}
+
+	return stillUnprocessed, nil
+}
+
+// unprocessedEmails extracts the "email" key attribute from whichever
+// requests are still unprocessed, so callers can mark the matching
+// BulkResult as failed.
+func unprocessedEmails(unprocessed []types.WriteRequest, keyOf func(types.WriteRequest) map[string]types.AttributeValue) map[string]bool {
+	emails := map[string]bool{}
+
+	for _, wr := range unprocessed {
+		key := keyOf(wr)
+		if email, ok := key["email"].(*types.AttributeValueMemberS); ok {
+			emails[email.Value] = true
+		}
+	}
+
+	return emails
+}
+
+func markBulkResults(results []BulkResult, failedEmails map[string]bool, failureReason string) {
+	for i := range results {
+		if results[i].Error != "" {
+			continue
+		}
+
+		if failedEmails[results[i].Email] {
+			results[i].Error = failureReason
+			continue
+		}
+
+		results[i].Success = true
+	}
 }
@@ -0,0 +1,356 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Gardego5/go-serverless-yt/pkg/txn"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type mockDynamoDBAPI struct {
+	getItem    func(context.Context, *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	putItem    func(context.Context, *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	updateItem func(context.Context, *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	deleteItem func(context.Context, *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
+	scan       func(context.Context, *dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
+	query      func(context.Context, *dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+	batchWrite func(context.Context, *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
+}
+
+func (m *mockDynamoDBAPI) GetItem(ctx context.Context, in *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return m.getItem(ctx, in)
+}
+
+func (m *mockDynamoDBAPI) PutItem(ctx context.Context, in *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	return m.putItem(ctx, in)
+}
+
+func (m *mockDynamoDBAPI) UpdateItem(ctx context.Context, in *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	return m.updateItem(ctx, in)
+}
+
+func (m *mockDynamoDBAPI) DeleteItem(ctx context.Context, in *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	return m.deleteItem(ctx, in)
+}
+
+func (m *mockDynamoDBAPI) Scan(ctx context.Context, in *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	return m.scan(ctx, in)
+}
+
+func (m *mockDynamoDBAPI) Query(ctx context.Context, in *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	return m.query(ctx, in)
+}
+
+func (m *mockDynamoDBAPI) BatchWriteItem(ctx context.Context, in *dynamodb.BatchWriteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	return m.batchWrite(ctx, in)
+}
+
+func patchRequest(body string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{Body: body}
+}
+
+func TestCreateUser_ConflictReturnsExisting(t *testing.T) {
+	existingAttrs, _ := attributevalue.MarshalMap(User{Email: "jane@example.com", FirstName: "Jane", LastName: "Doe"})
+
+	client := &mockDynamoDBAPI{
+		putItem: func(context.Context, *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			return nil, &types.ConditionalCheckFailedException{Item: existingAttrs}
+		},
+	}
+
+	_, err := CreateUser(context.Background(), patchRequest(`{"email":"jane@example.com","firstName":"Jane","lastName":"Doe"}`), "users", client)
+
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("expected a *ConflictError, got %v", err)
+	}
+	if conflictErr.Existing == nil || conflictErr.Existing.FirstName != "Jane" {
+		t.Fatalf("expected the existing record to be surfaced, got %+v", conflictErr.Existing)
+	}
+}
+
+type mockTxnDynamoDBAPI struct {
+	transactWriteItems func(context.Context, *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+func (m *mockTxnDynamoDBAPI) TransactWriteItems(ctx context.Context, in *dynamodb.TransactWriteItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	return m.transactWriteItems(ctx, in)
+}
+
+func TestCreateUser_ParticipatesInTransaction(t *testing.T) {
+	var captured *dynamodb.TransactWriteItemsInput
+	txnClient := &mockTxnDynamoDBAPI{
+		transactWriteItems: func(_ context.Context, in *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+			captured = in
+			return &dynamodb.TransactWriteItemsOutput{}, nil
+		},
+	}
+
+	builder := txn.New()
+	result, err := CreateUser(context.Background(), patchRequest(`{"email":"jane@example.com"}`), "users", nil, builder)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Email != "jane@example.com" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	if err := builder.Run(context.Background(), txnClient); err != nil {
+		t.Fatalf("unexpected error running the transaction: %v", err)
+	}
+	if len(captured.TransactItems) != 1 || captured.TransactItems[0].Put == nil {
+		t.Fatalf("expected CreateUser to enqueue a single Put, got %+v", captured.TransactItems)
+	}
+}
+
+func TestPatchUser_NonexistentUser(t *testing.T) {
+	client := &mockDynamoDBAPI{
+		updateItem: func(context.Context, *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			return nil, &types.ConditionalCheckFailedException{}
+		},
+	}
+
+	_, err := PatchUser(context.Background(), patchRequest(`{"email":"missing@example.com","firstName":"Jane"}`), "users", client)
+	if err == nil || err.Error() != ErrorUserDoesNotExist {
+		t.Fatalf("expected %q, got %v", ErrorUserDoesNotExist, err)
+	}
+}
+
+func TestPatchUser_SingleField(t *testing.T) {
+	var captured *dynamodb.UpdateItemInput
+
+	client := &mockDynamoDBAPI{
+		updateItem: func(_ context.Context, in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			captured = in
+			attrs, _ := attributevalue.MarshalMap(User{Email: "jane@example.com", FirstName: "Jane", LastName: "Doe"})
+			return &dynamodb.UpdateItemOutput{Attributes: attrs}, nil
+		},
+	}
+
+	result, err := PatchUser(context.Background(), patchRequest(`{"email":"jane@example.com","firstName":"Jane"}`), "users", client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.FirstName != "Jane" || result.LastName != "Doe" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if _, ok := captured.ExpressionAttributeNames["#lastName"]; ok {
+		t.Fatalf("did not expect lastName to be part of the update expression")
+	}
+	if _, ok := captured.ExpressionAttributeNames["#firstName"]; !ok {
+		t.Fatalf("expected firstName to be part of the update expression")
+	}
+}
+
+func TestPatchUser_BothFields(t *testing.T) {
+	var captured *dynamodb.UpdateItemInput
+
+	client := &mockDynamoDBAPI{
+		updateItem: func(_ context.Context, in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			captured = in
+			attrs, _ := attributevalue.MarshalMap(User{Email: "jane@example.com", FirstName: "Jane", LastName: "Smith"})
+			return &dynamodb.UpdateItemOutput{Attributes: attrs}, nil
+		},
+	}
+
+	result, err := PatchUser(context.Background(), patchRequest(`{"email":"jane@example.com","firstName":"Jane","lastName":"Smith"}`), "users", client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.FirstName != "Jane" || result.LastName != "Smith" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(captured.ExpressionAttributeNames) != 2 {
+		t.Fatalf("expected both fields in the update expression, got %v", captured.ExpressionAttributeNames)
+	}
+}
+
+func TestQueryUsers_FiltersByLastNameViaIndex(t *testing.T) {
+	var captured *dynamodb.QueryInput
+
+	attrs, _ := attributevalue.MarshalMap(User{Email: "jane@example.com", FirstName: "Jane", LastName: "Smith"})
+	client := &mockDynamoDBAPI{
+		query: func(_ context.Context, in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			captured = in
+			return &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{attrs}}, nil
+		},
+	}
+
+	page, err := QueryUsers(context.Background(), "users", client, QueryOptions{LastName: "Smith"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *captured.IndexName != LastNameIndex {
+		t.Fatalf("expected query against %q, got %q", LastNameIndex, *captured.IndexName)
+	}
+	if len(page.Users) != 1 || page.Users[0].LastName != "Smith" {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+
+	// Guards against the v2 attributevalue gotcha: without a dynamodbav tag it
+	// marshals the Go field name ("LastName"), not the GSI's "lastName"
+	// attribute, so the query would never match anything it wrote.
+	if av, ok := captured.ExpressionAttributeValues[":lastName"].(*types.AttributeValueMemberS); !ok || av.Value != "Smith" {
+		t.Fatalf("expected :lastName to carry the filter value, got %+v", captured.ExpressionAttributeValues)
+	}
+	if _, ok := attrs["lastName"]; !ok {
+		t.Fatalf(`expected MarshalMap to write a "lastName" attribute, got %v`, attrs)
+	}
+}
+
+func TestQueryUsers_CursorRoundTrip(t *testing.T) {
+	lastKey := map[string]types.AttributeValue{"email": &types.AttributeValueMemberS{Value: "jane@example.com"}}
+
+	client := &mockDynamoDBAPI{
+		scan: func(_ context.Context, in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			if in.ExclusiveStartKey != nil {
+				t.Fatalf("expected no ExclusiveStartKey on first page")
+			}
+			return &dynamodb.ScanOutput{LastEvaluatedKey: lastKey}, nil
+		},
+	}
+
+	page, err := QueryUsers(context.Background(), "users", client, QueryOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if page.NextCursor == "" {
+		t.Fatalf("expected a NextCursor when LastEvaluatedKey is set")
+	}
+
+	client.scan = func(_ context.Context, in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+		if in.ExclusiveStartKey == nil {
+			t.Fatalf("expected ExclusiveStartKey to be decoded from the cursor")
+		}
+		return &dynamodb.ScanOutput{}, nil
+	}
+
+	if _, err := QueryUsers(context.Background(), "users", client, QueryOptions{Cursor: page.NextCursor}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestBulkCreateUsers_PartialBatch(t *testing.T) {
+	client := &mockDynamoDBAPI{
+		batchWrite: func(_ context.Context, in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			unprocessed := []types.WriteRequest{}
+			for _, wr := range in.RequestItems["users"] {
+				if email, ok := wr.PutRequest.Item["email"].(*types.AttributeValueMemberS); ok && email.Value == "fails@example.com" {
+					unprocessed = append(unprocessed, wr)
+				}
+			}
+			return &dynamodb.BatchWriteItemOutput{
+				UnprocessedItems: map[string][]types.WriteRequest{"users": unprocessed},
+			}, nil
+		},
+	}
+
+	body := `[{"email":"jane@example.com"},{"email":"not-an-email"},{"email":"fails@example.com"}]`
+	results, err := BulkCreateUsers(context.Background(), patchRequest(body), "users", client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if !results[0].Success {
+		t.Fatalf("expected jane@example.com to succeed, got %+v", results[0])
+	}
+	if results[1].Success || results[1].Error != ErrorInvalidEmail {
+		t.Fatalf("expected invalid email to be rejected before submission, got %+v", results[1])
+	}
+	if results[2].Success || results[2].Error == "" {
+		t.Fatalf("expected fails@example.com to be reported as failed, got %+v", results[2])
+	}
+}
+
+func TestBulkCreateUsers_AbortsOnContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	client := &mockDynamoDBAPI{
+		batchWrite: func(_ context.Context, in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			cancel()
+			return &dynamodb.BatchWriteItemOutput{
+				UnprocessedItems: map[string][]types.WriteRequest{"users": in.RequestItems["users"]},
+			}, nil
+		},
+	}
+
+	start := time.Now()
+	if _, err := BulkCreateUsers(ctx, patchRequest(`[{"email":"jane@example.com"}]`), "users", client); err == nil {
+		t.Fatalf("expected an error once the context is canceled mid-retry")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the backoff to abort on cancellation instead of sleeping through all retries, took %s", elapsed)
+	}
+}
+
+func TestPatchUser_EmptyPatchIsNoop(t *testing.T) {
+	called := false
+
+	client := &mockDynamoDBAPI{
+		updateItem: func(context.Context, *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			called = true
+			return nil, nil
+		},
+		getItem: func(_ context.Context, in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			attrs, _ := attributevalue.MarshalMap(User{Email: "jane@example.com", FirstName: "Jane", LastName: "Doe"})
+			return &dynamodb.GetItemOutput{Item: attrs}, nil
+		},
+	}
+
+	result, err := PatchUser(context.Background(), patchRequest(`{"email":"jane@example.com"}`), "users", client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if called {
+		t.Fatalf("expected no-op patch to skip UpdateItem")
+	}
+	if result.FirstName != "Jane" || result.LastName != "Doe" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestPatchUser_EmptyPatchNonexistentUser(t *testing.T) {
+	client := &mockDynamoDBAPI{
+		getItem: func(context.Context, *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{}, nil
+		},
+	}
+
+	_, err := PatchUser(context.Background(), patchRequest(`{"email":"ghost@example.com"}`), "users", client)
+	if err == nil || err.Error() != ErrorUserDoesNotExist {
+		t.Fatalf("expected %q, got %v", ErrorUserDoesNotExist, err)
+	}
+}
+
+func TestDeleteUser_KeyOnlyContainsEmail(t *testing.T) {
+	var captured *dynamodb.DeleteItemInput
+
+	client := &mockDynamoDBAPI{
+		deleteItem: func(_ context.Context, in *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+			captured = in
+			return &dynamodb.DeleteItemOutput{}, nil
+		},
+	}
+
+	body := `{"email":"jane@example.com","firstName":"Jane","lastName":"Doe"}`
+	if err := DeleteUser(context.Background(), patchRequest(body), "users", client); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(captured.Key) != 1 {
+		t.Fatalf("expected Key to contain only the partition key, got %+v", captured.Key)
+	}
+	av, ok := captured.Key["email"].(*types.AttributeValueMemberS)
+	if !ok || av.Value != "jane@example.com" {
+		t.Fatalf("expected Key to carry the email, got %+v", captured.Key)
+	}
+}